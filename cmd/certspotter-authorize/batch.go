@@ -0,0 +1,183 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla
+// Public License, v. 2.0. If a copy of the MPL was not distributed
+// with this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This software is distributed WITHOUT A WARRANTY OF ANY KIND.
+// See the Mozilla Public License for details.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// BatchResult is the structured summary of one -batch manifest entry,
+// printed as a line of JSON so certspotter-authorize composes with other
+// tooling.
+type BatchResult struct {
+	Entry      string `json:"entry"`
+	TBSSHA256  string `json:"tbs_sha256,omitempty"`
+	MarkerPath string `json:"marker_path,omitempty"`
+	Status     string `json:"status"` // created, already, or error
+	DryRun     bool   `json:"dry_run,omitempty"` // true if status=="created" only describes what -dry-run would do, not what happened
+	Error      string `json:"error,omitempty"`
+}
+
+// readBatchManifest reads the manifest at path (or stdin, if path is "-"),
+// one entry per line.  Blank lines and lines starting with "#" are ignored.
+func readBatchManifest(path string) ([]string, error) {
+	var reader io.Reader
+	if path == "-" {
+		reader = os.Stdin
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	var entries []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+	return entries, nil
+}
+
+// looksLikeHostPort reports whether entry should be treated as a host:port
+// endpoint to fetch a certificate from, rather than a filesystem path.
+func looksLikeHostPort(entry string) bool {
+	if entry == "-" || fileExists(entry) {
+		return false
+	}
+	_, _, err := net.SplitHostPort(entry)
+	return err == nil
+}
+
+// resolveBatchEntry turns a manifest entry into a TBS hash, fetching or
+// reading a certificate as necessary.
+func resolveBatchEntry(entry, sniName, starttls string) ([32]byte, error) {
+	switch {
+	case strings.HasPrefix(entry, "sha256:"):
+		raw, err := hex.DecodeString(strings.TrimPrefix(entry, "sha256:"))
+		if err != nil || len(raw) != 32 {
+			return [32]byte{}, fmt.Errorf("invalid sha256 TBS hash %q", entry)
+		}
+		var tbsHash [32]byte
+		copy(tbsHash[:], raw)
+		return tbsHash, nil
+	case looksLikeHostPort(entry):
+		chain, err := fetchCertificateChain(entry, sniName, starttls)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		return computeTBSHash(chain[0])
+	default:
+		certBytes, err := readCertFile(entry)
+		if err != nil {
+			return [32]byte{}, fmt.Errorf("error reading certificate: %w", err)
+		}
+		certDER, err := parseCertificate(certBytes)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		return computeTBSHash(certDER)
+	}
+}
+
+// processBatchEntry resolves and, unless dryRun, authorizes a single
+// manifest entry against store.
+func processBatchEntry(ctx context.Context, store NotifiedStore, entry string, opts MarkOptions, sniName, starttls string, dryRun bool) *BatchResult {
+	result := &BatchResult{Entry: entry}
+
+	tbsHash, err := resolveBatchEntry(entry, sniName, starttls)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	result.TBSSHA256 = hex.EncodeToString(tbsHash[:])
+	if fsStore, ok := store.(*FilesystemNotifiedStore); ok {
+		if path, err := fsStore.markerPath(tbsHash); err == nil {
+			result.MarkerPath = path
+		}
+	}
+
+	already, err := store.IsNotified(ctx, tbsHash)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	if already {
+		result.Status = "already"
+		return result
+	}
+
+	if dryRun {
+		result.Status = "created"
+		result.DryRun = true
+		return result
+	}
+
+	if err := store.MarkNotified(ctx, tbsHash, opts); err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = "created"
+	return result
+}
+
+// runBatch processes entries against store with up to jobs entries in
+// flight at once, writing one JSON-encoded BatchResult per entry to out in
+// manifest order.  It returns false if any entry resulted in an error.
+func runBatch(ctx context.Context, store NotifiedStore, entries []string, opts MarkOptions, sniName, starttls string, dryRun bool, jobs int, out io.Writer) bool {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	results := make([]*BatchResult, len(entries))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, entry string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = processBatchEntry(ctx, store, entry, opts, sniName, starttls, dryRun)
+		}(i, entry)
+	}
+	wg.Wait()
+
+	ok := true
+	encoder := json.NewEncoder(out)
+	for _, result := range results {
+		if result.Status == "error" {
+			ok = false
+		}
+		encoder.Encode(result)
+	}
+	return ok
+}
@@ -0,0 +1,132 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla
+// Public License, v. 2.0. If a copy of the MPL was not distributed
+// with this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This software is distributed WITHOUT A WARRANTY OF ANY KIND.
+// See the Mozilla Public License for details.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadBatchManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.txt")
+	content := "cert1.pem\n\n# a comment\nsha256:" + strings.Repeat("ab", 32) + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+
+	entries, err := readBatchManifest(path)
+	if err != nil {
+		t.Fatalf("readBatchManifest failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, expected 2: %v", len(entries), entries)
+	}
+}
+
+func TestProcessBatchEntrySHA256(t *testing.T) {
+	ctx := context.Background()
+	store := NewFilesystemNotifiedStore(t.TempDir())
+
+	var tbsHash [32]byte
+	tbsHash[0] = 0x42
+	entry := "sha256:" + hex.EncodeToString(tbsHash[:])
+
+	result := processBatchEntry(ctx, store, entry, MarkOptions{}, "", "", false)
+	if result.Status != "created" {
+		t.Fatalf("unexpected status: %+v", result)
+	}
+	if result.TBSSHA256 != hex.EncodeToString(tbsHash[:]) {
+		t.Fatalf("unexpected tbs_sha256: %+v", result)
+	}
+
+	result = processBatchEntry(ctx, store, entry, MarkOptions{}, "", "", false)
+	if result.Status != "already" {
+		t.Fatalf("expected already on second pass, got: %+v", result)
+	}
+}
+
+func TestProcessBatchEntryDryRun(t *testing.T) {
+	ctx := context.Background()
+	store := NewFilesystemNotifiedStore(t.TempDir())
+
+	var tbsHash [32]byte
+	tbsHash[0] = 0x99
+	entry := "sha256:" + hex.EncodeToString(tbsHash[:])
+
+	result := processBatchEntry(ctx, store, entry, MarkOptions{}, "", "", true)
+	if result.Status != "created" {
+		t.Fatalf("unexpected status: %+v", result)
+	}
+	if !result.DryRun {
+		t.Fatal("dry-run result did not set DryRun")
+	}
+
+	notified, err := store.IsNotified(ctx, tbsHash)
+	if err != nil {
+		t.Fatalf("IsNotified failed: %v", err)
+	}
+	if notified {
+		t.Fatal("dry-run unexpectedly wrote state")
+	}
+}
+
+func TestProcessBatchEntryInvalidSHA256(t *testing.T) {
+	ctx := context.Background()
+	store := NewFilesystemNotifiedStore(t.TempDir())
+
+	result := processBatchEntry(ctx, store, "sha256:not-hex", MarkOptions{}, "", "", false)
+	if result.Status != "error" {
+		t.Fatalf("expected error status, got: %+v", result)
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	ctx := context.Background()
+	store := NewFilesystemNotifiedStore(t.TempDir())
+
+	var a, b [32]byte
+	a[0], b[0] = 1, 2
+	entries := []string{
+		"sha256:" + hex.EncodeToString(a[:]),
+		"sha256:" + hex.EncodeToString(b[:]),
+		"sha256:not-hex",
+	}
+
+	var buf bytes.Buffer
+	ok := runBatch(ctx, store, entries, MarkOptions{}, "", "", false, 2, &buf)
+	if ok {
+		t.Fatal("expected runBatch to report failure due to the invalid entry")
+	}
+
+	decoder := json.NewDecoder(&buf)
+	var results []BatchResult
+	for decoder.More() {
+		var r BatchResult
+		if err := decoder.Decode(&r); err != nil {
+			t.Fatalf("error decoding result: %v", err)
+		}
+		results = append(results, r)
+	}
+	if len(results) != len(entries) {
+		t.Fatalf("got %d results, expected %d", len(results), len(entries))
+	}
+	if results[0].Entry != entries[0] || results[0].Status != "created" {
+		t.Fatalf("unexpected first result: %+v", results[0])
+	}
+	if results[2].Status != "error" {
+		t.Fatalf("unexpected third result: %+v", results[2])
+	}
+}
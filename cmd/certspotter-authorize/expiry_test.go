@@ -0,0 +1,82 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla
+// Public License, v. 2.0. If a copy of the MPL was not distributed
+// with this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This software is distributed WITHOUT A WARRANTY OF ANY KIND.
+// See the Mozilla Public License for details.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFilesystemNotifiedStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	store := NewFilesystemNotifiedStore(stateDir)
+
+	certDER, err := parseCertificate([]byte(testCertPEM))
+	if err != nil {
+		t.Fatalf("parseCertificate failed: %v", err)
+	}
+	tbsHash, err := computeTBSHash(certDER)
+	if err != nil {
+		t.Fatalf("computeTBSHash failed: %v", err)
+	}
+
+	opts := MarkOptions{ExpiresAt: time.Now().Add(-time.Hour), Reason: "test"}
+	if err := store.MarkNotified(ctx, tbsHash, opts); err != nil {
+		t.Fatalf("MarkNotified failed: %v", err)
+	}
+
+	if notified, err := store.IsNotified(ctx, tbsHash); err != nil {
+		t.Fatalf("IsNotified failed: %v", err)
+	} else if notified {
+		t.Fatal("IsNotified returned true for an expired marker")
+	}
+}
+
+func TestFilesystemNotifiedStoreGC(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	store := NewFilesystemNotifiedStore(stateDir)
+
+	certDER, err := parseCertificate([]byte(testCertPEM))
+	if err != nil {
+		t.Fatalf("parseCertificate failed: %v", err)
+	}
+	tbsHash, err := computeTBSHash(certDER)
+	if err != nil {
+		t.Fatalf("computeTBSHash failed: %v", err)
+	}
+
+	// An expired marker should be swept...
+	if err := store.MarkNotified(ctx, tbsHash, MarkOptions{ExpiresAt: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatalf("MarkNotified failed: %v", err)
+	}
+	// ...but a permanent marker for a different certificate should survive.
+	var otherHash [32]byte
+	otherHash[0] = 1
+	if err := store.MarkNotified(ctx, otherHash, MarkOptions{}); err != nil {
+		t.Fatalf("MarkNotified failed: %v", err)
+	}
+
+	removed, err := store.GC(ctx)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("GC removed %d markers, expected 1", removed)
+	}
+
+	if notified, err := store.IsNotified(ctx, otherHash); err != nil {
+		t.Fatalf("IsNotified failed: %v", err)
+	} else if !notified {
+		t.Fatal("GC removed the permanent marker")
+	}
+}
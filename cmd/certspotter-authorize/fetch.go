@@ -0,0 +1,161 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla
+// Public License, v. 2.0. If a copy of the MPL was not distributed
+// with this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This software is distributed WITHOUT A WARRANTY OF ANY KIND.
+// See the Mozilla Public License for details.
+
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// fetchCertificateChain connects to hostPort, optionally issuing a STARTTLS
+// command first, and returns the DER-encoded certificate chain the server
+// presents, leaf certificate first.  Verification of the chain is skipped:
+// the caller already trusts hostPort (it's one of their own endpoints) and
+// only wants the certificate it's currently serving.
+func fetchCertificateChain(hostPort, sniName, starttls string) ([][]byte, error) {
+	conn, err := net.DialTimeout("tcp", hostPort, 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", hostPort, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	if sniName == "" {
+		if host, _, err := net.SplitHostPort(hostPort); err == nil {
+			sniName = host
+		} else {
+			sniName = hostPort
+		}
+	}
+
+	if starttls != "" {
+		if err := startTLS(conn, starttls, sniName); err != nil {
+			return nil, fmt.Errorf("error performing STARTTLS on %s: %w", hostPort, err)
+		}
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         sniName,
+		InsecureSkipVerify: true,
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, fmt.Errorf("error performing TLS handshake with %s: %w", hostPort, err)
+	}
+
+	peerCerts := tlsConn.ConnectionState().PeerCertificates
+	if len(peerCerts) == 0 {
+		return nil, fmt.Errorf("%s did not present any certificates", hostPort)
+	}
+
+	chain := make([][]byte, len(peerCerts))
+	for i, cert := range peerCerts {
+		chain[i] = cert.Raw
+	}
+	return chain, nil
+}
+
+// startTLS speaks just enough of protocol's plaintext greeting to tell the
+// server to begin a TLS handshake on the current connection.
+func startTLS(conn net.Conn, protocol, domain string) error {
+	switch protocol {
+	case "smtp":
+		return startTLSSMTP(conn)
+	case "imap":
+		return startTLSIMAP(conn)
+	case "xmpp":
+		return startTLSXMPP(conn, domain)
+	default:
+		return fmt.Errorf("unsupported -starttls protocol %q", protocol)
+	}
+}
+
+func startTLSSMTP(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	if _, err := readSMTPResponse(r); err != nil { // server banner
+		return err
+	}
+	if _, err := fmt.Fprintf(conn, "EHLO certspotter-authorize\r\n"); err != nil {
+		return err
+	}
+	if _, err := readSMTPResponse(r); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(conn, "STARTTLS\r\n"); err != nil {
+		return err
+	}
+	code, err := readSMTPResponse(r)
+	if err != nil {
+		return err
+	}
+	if code != 220 {
+		return fmt.Errorf("server rejected STARTTLS with code %d", code)
+	}
+	return nil
+}
+
+// readSMTPResponse reads a (possibly multi-line) SMTP response and returns
+// its reply code.
+func readSMTPResponse(r *bufio.Reader) (int, error) {
+	var code int
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		if len(line) < 4 {
+			return 0, fmt.Errorf("malformed SMTP response: %q", line)
+		}
+		if _, err := fmt.Sscanf(line[0:3], "%d", &code); err != nil {
+			return 0, fmt.Errorf("malformed SMTP response: %q", line)
+		}
+		if line[3] == ' ' {
+			return code, nil
+		}
+	}
+}
+
+func startTLSIMAP(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	if _, err := r.ReadString('\n'); err != nil { // server greeting
+		return err
+	}
+	if _, err := fmt.Fprintf(conn, "a1 STARTTLS\r\n"); err != nil {
+		return err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "a1 OK") {
+		return fmt.Errorf("server rejected STARTTLS: %q", strings.TrimSpace(line))
+	}
+	return nil
+}
+
+func startTLSXMPP(conn net.Conn, domain string) error {
+	r := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "<?xml version='1.0'?><stream:stream to='%s' xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>\n", domain)
+	if _, err := r.ReadString('>'); err != nil { // opening <stream:stream ...>
+		return err
+	}
+	fmt.Fprintf(conn, "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+	reply, err := r.ReadString('>')
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(reply, "proceed") {
+		return fmt.Errorf("server rejected STARTTLS: %q", reply)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla
+// Public License, v. 2.0. If a copy of the MPL was not distributed
+// with this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This software is distributed WITHOUT A WARRANTY OF ANY KIND.
+// See the Mozilla Public License for details.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func selfSignedTLSCert(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestFetchCertificateChain(t *testing.T) {
+	cert := selfSignedTLSCert(t)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("tls.Listen failed: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	chain, err := fetchCertificateChain(listener.Addr().String(), "test.example.com", "")
+	if err != nil {
+		t.Fatalf("fetchCertificateChain failed: %v", err)
+	}
+	if len(chain) != 1 {
+		t.Fatalf("got %d certificates, expected 1", len(chain))
+	}
+
+	tbsHash, err := computeTBSHash(chain[0])
+	if err != nil {
+		t.Fatalf("computeTBSHash failed: %v", err)
+	}
+	zeroHash := [32]byte{}
+	if tbsHash == zeroHash {
+		t.Fatal("computeTBSHash returned zero hash for fetched certificate")
+	}
+}
+
+func TestFetchCertificateChainConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen failed: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	if _, err := fetchCertificateChain(addr, "", ""); err == nil {
+		t.Fatal("expected error connecting to closed port")
+	}
+}
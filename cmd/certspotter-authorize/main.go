@@ -10,17 +10,20 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
-	"encoding/hex"
 	"encoding/pem"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/user"
 	"path/filepath"
+	"regexp"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"time"
 
 	"software.sslmate.com/src/certspotter"
 )
@@ -64,6 +67,13 @@ func fileExists(filename string) bool {
 	return err == nil
 }
 
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return ""
+}
+
 func readCertFile(path string) ([]byte, error) {
 	var reader io.Reader
 	if path == "-" {
@@ -100,82 +110,268 @@ func computeTBSHash(certDER []byte) ([32]byte, error) {
 	return sha256.Sum256(certInfo.TBS.Raw), nil
 }
 
-func createNotifiedMarker(stateDir string, tbsHash [32]byte) (string, error) {
-	tbsHex := hex.EncodeToString(tbsHash[:])
-	if len(tbsHex) < 2 {
-		return "", fmt.Errorf("TBS hash hex is too short: %d characters", len(tbsHex))
+func main() {
+	version, source := certspotterVersion()
+
+	var flags struct {
+		cert          string
+		stateDir      string
+		store         string
+		version       bool
+		rule          bool
+		list          bool
+		remove        string
+		issuer        string
+		san           string
+		spki          string
+		expiresBefore string
+		maxUses       int
+		host          string
+		sni           string
+		starttls      string
+		chain         bool
+		ttl           time.Duration
+		until         string
+		reason        string
+		gc            bool
+		batch         string
+		dryRun        bool
+		jobs          int
+		check         bool
 	}
 
-	tbsDir := filepath.Join(stateDir, "certs", tbsHex[0:2])
-	notifiedPath := filepath.Join(tbsDir, "."+tbsHex+".notified")
+	flag.StringVar(&flags.cert, "cert", "", "Path to a PEM or DER encoded certificate (use - to read from stdin)")
+	flag.StringVar(&flags.stateDir, "state_dir", defaultStateDir(), "State directory used by certspotter")
+	flag.StringVar(&flags.store, "store", os.Getenv("CERTSPOTTER_STATE_URL"), "State store URL (default: filesystem layout under -state_dir; e.g. sqlite:///var/lib/certspotter/state.db)")
+	flag.BoolVar(&flags.version, "version", false, "Print version and exit")
+	flag.BoolVar(&flags.rule, "rule", false, "Add an authorization rule matching a pattern of certificates, instead of authorizing a single certificate")
+	flag.BoolVar(&flags.list, "list", false, "List authorization rules and exit")
+	flag.StringVar(&flags.remove, "remove", "", "Remove the authorization rule with the given ID and exit")
+	flag.StringVar(&flags.issuer, "issuer", "", "With -rule: regular expression matched against the issuer distinguished name")
+	flag.StringVar(&flags.san, "san", "", "With -rule: DNS SAN pattern to match, optionally prefixed with \"*.\" for a wildcard")
+	flag.StringVar(&flags.spki, "spki", "", "With -rule: hex-encoded SHA-256 of the certificate's SubjectPublicKeyInfo to match")
+	flag.StringVar(&flags.expiresBefore, "expires-before", "", "With -rule: only match certificates whose NotAfter is before this RFC 3339 time")
+	flag.IntVar(&flags.maxUses, "max-uses", 0, "With -rule: maximum number of times the rule may match (0 means unlimited)")
+	flag.StringVar(&flags.host, "host", "", "Fetch the certificate from host:port instead of -cert")
+	flag.StringVar(&flags.sni, "sni", "", "With -host: server name to send via SNI (default: the host from -host)")
+	flag.StringVar(&flags.starttls, "starttls", "", "With -host: perform STARTTLS for the given protocol before the TLS handshake (smtp, imap, or xmpp)")
+	flag.BoolVar(&flags.chain, "chain", false, "With -host: authorize every certificate in the presented chain, not just the leaf")
+	flag.DurationVar(&flags.ttl, "ttl", 0, "Authorize the certificate for this long instead of forever (e.g. 720h)")
+	flag.StringVar(&flags.until, "until", "", "Authorize the certificate until this RFC 3339 time instead of forever")
+	flag.StringVar(&flags.reason, "reason", "", "Human-readable reason recorded alongside the authorization")
+	flag.BoolVar(&flags.gc, "gc", false, "Remove expired authorizations from the state store (-store, or -state_dir by default) and exit")
+	flag.StringVar(&flags.batch, "batch", "", "Authorize every entry in the manifest at PATH (use - to read from stdin): one per line, each a file path, sha256:<hex> TBS hash, or host:port")
+	flag.BoolVar(&flags.dryRun, "dry-run", false, "With -batch: report what would be authorized without touching state")
+	flag.IntVar(&flags.jobs, "jobs", 1, "With -batch: number of entries to process concurrently")
+	flag.BoolVar(&flags.check, "check", false, "Instead of unconditionally authorizing the certificate, only authorize it if it matches a stored authorization rule")
+	flag.Parse()
 
-	// Check if already notified
-	if fileExists(notifiedPath) {
-		return notifiedPath, nil
+	if flags.version {
+		fmt.Fprintf(os.Stdout, "certspotter-authorize version %s (%s)\n", version, source)
+		os.Exit(0)
 	}
 
-	// Create directory if needed
-	if err := os.MkdirAll(tbsDir, 0777); err != nil {
-		return "", fmt.Errorf("error creating directory: %w", err)
+	if flags.list {
+		rules, err := loadAuthorizationRules(flags.stateDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+			os.Exit(1)
+		}
+		for _, rule := range rules {
+			fmt.Printf("%s\tissuer=%q\tsan=%q\tspki=%q\tmax_uses=%d\tmatched_count=%d\n",
+				rule.ID, rule.IssuerDNRegex, rule.SANPattern, rule.SPKISHA256, rule.MaxUses, rule.MatchedCount)
+		}
+		os.Exit(0)
 	}
 
-	// Create marker file
-	if err := os.WriteFile(notifiedPath, nil, 0666); err != nil {
-		return "", fmt.Errorf("error creating marker file: %w", err)
+	if flags.remove != "" {
+		if err := removeAuthorizationRule(flags.stateDir, flags.remove); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
 
-	return notifiedPath, nil
-}
+	if flags.gc {
+		store, err := openNotifiedStore(flags.stateDir, flags.store)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+			os.Exit(1)
+		}
+		defer store.Close()
+		removed, err := store.GC(context.Background())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+			os.Exit(1)
+		}
+		fmt.Printf("removed %d expired marker(s)\n", removed)
+		os.Exit(0)
+	}
 
-func main() {
-	version, source := certspotterVersion()
+	if flags.rule {
+		if flags.issuer != "" {
+			if _, err := regexp.Compile(flags.issuer); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: invalid -issuer regular expression: %s\n", programName, err)
+				os.Exit(1)
+			}
+		}
+		rule := &AuthorizationRule{
+			IssuerDNRegex: flags.issuer,
+			SANPattern:    flags.san,
+			SPKISHA256:    flags.spki,
+			MaxUses:       flags.maxUses,
+		}
+		if flags.expiresBefore != "" {
+			notAfterBefore, err := time.Parse(time.RFC3339, flags.expiresBefore)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: invalid -expires-before value: %s\n", programName, err)
+				os.Exit(1)
+			}
+			rule.NotAfterBefore = notAfterBefore
+		}
+		id, err := newRuleID()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+			os.Exit(1)
+		}
+		rule.ID = id
+		if err := saveAuthorizationRule(flags.stateDir, rule); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+			os.Exit(1)
+		}
+		fmt.Println(rule.ID)
+		os.Exit(0)
+	}
 
-	var flags struct {
-		cert     string
-		stateDir string
-		version  bool
+	if flags.ttl != 0 && flags.until != "" {
+		fmt.Fprintf(os.Stderr, "%s: specify only one of -ttl or -until\n", programName)
+		os.Exit(2)
 	}
 
-	flag.StringVar(&flags.cert, "cert", "", "Path to a PEM or DER encoded certificate (use - to read from stdin)")
-	flag.StringVar(&flags.stateDir, "state_dir", defaultStateDir(), "State directory used by certspotter")
-	flag.BoolVar(&flags.version, "version", false, "Print version and exit")
-	flag.Parse()
+	opts := MarkOptions{Reason: flags.reason, AuthorizedBy: currentUser()}
+	switch {
+	case flags.ttl != 0:
+		opts.ExpiresAt = time.Now().Add(flags.ttl)
+	case flags.until != "":
+		expiresAt, err := time.Parse(time.RFC3339, flags.until)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid -until value: %s\n", programName, err)
+			os.Exit(1)
+		}
+		opts.ExpiresAt = expiresAt
+	}
 
-	if flags.version {
-		fmt.Fprintf(os.Stdout, "certspotter-authorize version %s (%s)\n", version, source)
+	if flags.batch != "" {
+		entries, err := readBatchManifest(flags.batch)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: error reading -batch manifest: %s\n", programName, err)
+			os.Exit(1)
+		}
+		store, err := openNotifiedStore(flags.stateDir, flags.store)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+			os.Exit(1)
+		}
+		defer store.Close()
+
+		if !runBatch(context.Background(), store, entries, opts, flags.sni, flags.starttls, flags.dryRun, flags.jobs, os.Stdout) {
+			os.Exit(1)
+		}
 		os.Exit(0)
 	}
 
-	if flags.cert == "" {
-		fmt.Fprintf(os.Stderr, "Usage: %s -cert PATH [-state_dir PATH]\n\n", programName)
-		fmt.Fprintf(os.Stderr, "Compute TBSCertificate SHA-256 and create a .notified marker to suppress\n")
+	if flags.cert == "" && flags.host == "" {
+		fmt.Fprintf(os.Stderr, "Usage: %s -cert PATH [-state_dir PATH] [-store URL]\n\n", programName)
+		fmt.Fprintf(os.Stderr, "Compute TBSCertificate SHA-256 and mark it as notified to suppress\n")
 		fmt.Fprintf(os.Stderr, "future certspotter notifications for certificates with the same TBSCertificate.\n\n")
+		fmt.Fprintf(os.Stderr, "Use -host host:port instead of -cert to fetch the certificate directly\n")
+		fmt.Fprintf(os.Stderr, "from a live TLS endpoint, -batch PATH to authorize a manifest of\n")
+		fmt.Fprintf(os.Stderr, "certificates, -rule to pre-approve a pattern of certificates instead of\n")
+		fmt.Fprintf(os.Stderr, "a single one, -list to show existing rules, or -remove ID to delete one.\n\n")
+		fmt.Fprintf(os.Stderr, "Use -check to only authorize the certificate if it matches a stored\n")
+		fmt.Fprintf(os.Stderr, "authorization rule, instead of authorizing it unconditionally.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
 		os.Exit(2)
 	}
-
-	certBytes, err := readCertFile(flags.cert)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s: error reading certificate: %s\n", programName, err)
-		os.Exit(1)
+	if flags.cert != "" && flags.host != "" {
+		fmt.Fprintf(os.Stderr, "%s: specify only one of -cert or -host\n", programName)
+		os.Exit(2)
 	}
 
-	certDER, err := parseCertificate(certBytes)
+	store, err := openNotifiedStore(flags.stateDir, flags.store)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
 		os.Exit(1)
 	}
+	defer store.Close()
 
-	tbsHash, err := computeTBSHash(certDER)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
-		os.Exit(1)
+	var certDERs [][]byte
+	if flags.host != "" {
+		chain, err := fetchCertificateChain(flags.host, flags.sni, flags.starttls)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+			os.Exit(1)
+		}
+		if flags.chain {
+			certDERs = chain
+		} else {
+			certDERs = chain[:1]
+		}
+	} else {
+		certBytes, err := readCertFile(flags.cert)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: error reading certificate: %s\n", programName, err)
+			os.Exit(1)
+		}
+		certDER, err := parseCertificate(certBytes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+			os.Exit(1)
+		}
+		certDERs = [][]byte{certDER}
 	}
 
-	_, err = createNotifiedMarker(flags.stateDir, tbsHash)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+	ctx := context.Background()
+	matched := false
+	for _, certDER := range certDERs {
+		tbsHash, err := computeTBSHash(certDER)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+			os.Exit(1)
+		}
+
+		if flags.check {
+			facts, err := certificateFacts(certDER)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+				os.Exit(1)
+			}
+			rule, err := checkAuthorizationRules(flags.stateDir, facts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+				os.Exit(1)
+			}
+			if rule == nil {
+				continue
+			}
+			if err := store.MarkNotified(ctx, tbsHash, opts); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+				os.Exit(1)
+			}
+			fmt.Printf("matched rule %s (matched_count=%d)\n", rule.ID, rule.MatchedCount)
+			matched = true
+			continue
+		}
+
+		if err := store.MarkNotified(ctx, tbsHash, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", programName, err)
+			os.Exit(1)
+		}
+	}
+
+	if flags.check && !matched {
+		fmt.Fprintf(os.Stderr, "%s: certificate did not match any authorization rule\n", programName)
 		os.Exit(1)
 	}
 
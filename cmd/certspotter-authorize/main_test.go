@@ -12,7 +12,6 @@ package main
 import (
 	"bytes"
 	"crypto/sha256"
-	"encoding/hex"
 	"os"
 	"path/filepath"
 	"testing"
@@ -95,47 +94,6 @@ func TestComputeTBSHash(t *testing.T) {
 	}
 }
 
-func TestCreateNotifiedMarker(t *testing.T) {
-	stateDir := t.TempDir()
-
-	certDER, err := parseCertificate([]byte(testCertPEM))
-	if err != nil {
-		t.Fatalf("parseCertificate failed: %v", err)
-	}
-
-	tbsHash, err := computeTBSHash(certDER)
-	if err != nil {
-		t.Fatalf("computeTBSHash failed: %v", err)
-	}
-
-	// First call should create the marker
-	notifiedPath, err := createNotifiedMarker(stateDir, tbsHash)
-	if err != nil {
-		t.Fatalf("createNotifiedMarker failed: %v", err)
-	}
-
-	// Verify marker file exists
-	if !fileExists(notifiedPath) {
-		t.Fatalf("marker file does not exist: %s", notifiedPath)
-	}
-
-	// Verify path structure is correct
-	tbsHex := hex.EncodeToString(tbsHash[:])
-	expectedPath := filepath.Join(stateDir, "certs", tbsHex[0:2], "."+tbsHex+".notified")
-	if notifiedPath != expectedPath {
-		t.Fatalf("unexpected marker path: got %s, expected %s", notifiedPath, expectedPath)
-	}
-
-	// Second call should succeed (idempotency)
-	notifiedPath2, err := createNotifiedMarker(stateDir, tbsHash)
-	if err != nil {
-		t.Fatalf("createNotifiedMarker second call failed: %v", err)
-	}
-	if notifiedPath != notifiedPath2 {
-		t.Fatalf("second call returned different path: got %s, expected %s", notifiedPath2, notifiedPath)
-	}
-}
-
 func TestReadCertFile(t *testing.T) {
 	// Test reading from a file
 	tmpDir := t.TempDir()
@@ -171,43 +129,3 @@ func TestFileExists(t *testing.T) {
 	}
 }
 
-func TestEndToEnd(t *testing.T) {
-	stateDir := t.TempDir()
-
-	certDER, err := parseCertificate([]byte(testCertPEM))
-	if err != nil {
-		t.Fatalf("parseCertificate failed: %v", err)
-	}
-
-	tbsHash, err := computeTBSHash(certDER)
-	if err != nil {
-		t.Fatalf("computeTBSHash failed: %v", err)
-	}
-
-	notifiedPath, err := createNotifiedMarker(stateDir, tbsHash)
-	if err != nil {
-		t.Fatalf("createNotifiedMarker failed: %v", err)
-	}
-
-	// Verify the marker file structure matches what monitor/fsstate.go expects
-	tbsHex := hex.EncodeToString(tbsHash[:])
-	expectedDir := filepath.Join(stateDir, "certs", tbsHex[0:2])
-	expectedFile := filepath.Join(expectedDir, "."+tbsHex+".notified")
-
-	if notifiedPath != expectedFile {
-		t.Fatalf("unexpected marker path: got %s, expected %s", notifiedPath, expectedFile)
-	}
-
-	if !fileExists(expectedFile) {
-		t.Fatalf("marker file does not exist: %s", expectedFile)
-	}
-
-	// Verify file is empty (as expected by certspotter)
-	stat, err := os.Stat(expectedFile)
-	if err != nil {
-		t.Fatalf("failed to stat marker file: %v", err)
-	}
-	if stat.Size() != 0 {
-		t.Fatalf("marker file should be empty, but has size %d", stat.Size())
-	}
-}
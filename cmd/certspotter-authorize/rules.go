@@ -0,0 +1,215 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla
+// Public License, v. 2.0. If a copy of the MPL was not distributed
+// with this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This software is distributed WITHOUT A WARRANTY OF ANY KIND.
+// See the Mozilla Public License for details.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AuthorizationRule is a standing pre-approval for certificates matching a
+// pattern, rather than a single TBSCertificate.  It lets an operator
+// pre-approve something like "any certificate our ACME client issues for
+// *.example.com" instead of re-authorizing every reissuance by hash.
+//
+// Rules are persisted as one JSON file per rule under
+// <stateDir>/authorizations/<id>.json.  A rule with a zero-value field
+// imposes no constraint of that kind.
+type AuthorizationRule struct {
+	ID             string    `json:"id"`
+	IssuerDNRegex  string    `json:"issuer_dn_regex,omitempty"`
+	SANPattern     string    `json:"san_pattern,omitempty"`
+	SPKISHA256     string    `json:"spki_sha256,omitempty"`
+	NotAfterBefore time.Time `json:"not_after_before,omitempty"`
+	MaxUses        int       `json:"max_uses,omitempty"`
+	MatchedCount   int       `json:"matched_count"`
+}
+
+// CertificateFacts are the attributes of a certificate that authorization
+// rules are matched against.
+type CertificateFacts struct {
+	IssuerDN   string
+	SANs       []string
+	SPKISHA256 [32]byte
+	NotAfter   time.Time
+}
+
+func certificateFacts(certDER []byte) (*CertificateFacts, error) {
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing certificate: %w", err)
+	}
+	return &CertificateFacts{
+		IssuerDN:   cert.Issuer.String(),
+		SANs:       cert.DNSNames,
+		SPKISHA256: sha256.Sum256(cert.RawSubjectPublicKeyInfo),
+		NotAfter:   cert.NotAfter,
+	}, nil
+}
+
+// Matches reports whether facts satisfies every constraint set on r.  A rule
+// that has already reached its MaxUses never matches again.
+func (r *AuthorizationRule) Matches(facts *CertificateFacts) (bool, error) {
+	if r.MaxUses > 0 && r.MatchedCount >= r.MaxUses {
+		return false, nil
+	}
+	if r.IssuerDNRegex != "" {
+		re, err := regexp.Compile(r.IssuerDNRegex)
+		if err != nil {
+			return false, fmt.Errorf("invalid issuer_dn_regex %q: %w", r.IssuerDNRegex, err)
+		}
+		if !re.MatchString(facts.IssuerDN) {
+			return false, nil
+		}
+	}
+	if r.SANPattern != "" {
+		matched := false
+		for _, san := range facts.SANs {
+			if matchSANPattern(r.SANPattern, san) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	if r.SPKISHA256 != "" && !strings.EqualFold(r.SPKISHA256, hex.EncodeToString(facts.SPKISHA256[:])) {
+		return false, nil
+	}
+	if !r.NotAfterBefore.IsZero() && !facts.NotAfter.Before(r.NotAfterBefore) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// matchSANPattern matches name against pattern, where a pattern starting
+// with "*." matches exactly one leading DNS label, the same as a wildcard
+// certificate would.
+func matchSANPattern(pattern, name string) bool {
+	if !strings.HasPrefix(pattern, "*.") {
+		return strings.EqualFold(pattern, name)
+	}
+	suffix := pattern[1:] // ".example.com"
+	if !strings.HasSuffix(strings.ToLower(name), strings.ToLower(suffix)) {
+		return false
+	}
+	label := name[:len(name)-len(suffix)]
+	return label != "" && !strings.Contains(label, ".")
+}
+
+func authorizationsDir(stateDir string) string {
+	return filepath.Join(stateDir, "authorizations")
+}
+
+func ruleFilePath(stateDir, id string) string {
+	return filepath.Join(authorizationsDir(stateDir), id+".json")
+}
+
+func newRuleID() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", fmt.Errorf("error generating rule ID: %w", err)
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
+
+func saveAuthorizationRule(stateDir string, rule *AuthorizationRule) error {
+	if err := os.MkdirAll(authorizationsDir(stateDir), 0777); err != nil {
+		return fmt.Errorf("error creating authorizations directory: %w", err)
+	}
+	data, err := json.MarshalIndent(rule, "", "\t")
+	if err != nil {
+		return fmt.Errorf("error marshaling authorization rule: %w", err)
+	}
+	if err := os.WriteFile(ruleFilePath(stateDir, rule.ID), data, 0666); err != nil {
+		return fmt.Errorf("error writing authorization rule: %w", err)
+	}
+	return nil
+}
+
+func loadAuthorizationRules(stateDir string) ([]*AuthorizationRule, error) {
+	entries, err := os.ReadDir(authorizationsDir(stateDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading authorizations directory: %w", err)
+	}
+
+	rules := make([]*AuthorizationRule, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(authorizationsDir(stateDir), entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading authorization rule %s: %w", path, err)
+		}
+		var rule AuthorizationRule
+		if err := json.Unmarshal(data, &rule); err != nil {
+			return nil, fmt.Errorf("error parsing authorization rule %s: %w", path, err)
+		}
+		rules = append(rules, &rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules, nil
+}
+
+func removeAuthorizationRule(stateDir, id string) error {
+	if err := os.Remove(ruleFilePath(stateDir, id)); err != nil {
+		return fmt.Errorf("error removing authorization rule %s: %w", id, err)
+	}
+	return nil
+}
+
+// checkAuthorizationRules evaluates facts against the rules stored under
+// stateDir, in the order loadAuthorizationRules returns them, and returns
+// the first one that matches.  The matching rule's MatchedCount is
+// incremented and persisted back to stateDir before it's returned, so a
+// rule with MaxUses set eventually stops matching.  If no rule matches, it
+// returns nil, nil.
+//
+// A rule that fails to evaluate (for example, one saved with an
+// IssuerDNRegex that somehow isn't valid regexp) is skipped rather than
+// aborting the whole pass, so that one bad rule doesn't wedge -check for
+// every other rule in the store.
+func checkAuthorizationRules(stateDir string, facts *CertificateFacts) (*AuthorizationRule, error) {
+	rules, err := loadAuthorizationRules(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		matched, err := rule.Matches(facts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: skipping authorization rule %s: %s\n", programName, rule.ID, err)
+			continue
+		}
+		if !matched {
+			continue
+		}
+		rule.MatchedCount++
+		if err := saveAuthorizationRule(stateDir, rule); err != nil {
+			return nil, err
+		}
+		return rule, nil
+	}
+	return nil, nil
+}
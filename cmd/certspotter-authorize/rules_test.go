@@ -0,0 +1,101 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla
+// Public License, v. 2.0. If a copy of the MPL was not distributed
+// with this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This software is distributed WITHOUT A WARRANTY OF ANY KIND.
+// See the Mozilla Public License for details.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchSANPattern(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"example.com", "example.com", true},
+		{"example.com", "www.example.com", false},
+		{"*.example.com", "www.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "a.b.example.com", false},
+		{"*.EXAMPLE.com", "www.example.com", true},
+	}
+	for _, c := range cases {
+		if got := matchSANPattern(c.pattern, c.name); got != c.want {
+			t.Errorf("matchSANPattern(%q, %q) = %v, want %v", c.pattern, c.name, got, c.want)
+		}
+	}
+}
+
+func TestAuthorizationRuleMatches(t *testing.T) {
+	facts := &CertificateFacts{
+		IssuerDN: "CN=Let's Encrypt,O=Let's Encrypt,C=US",
+		SANs:     []string{"www.example.com"},
+		NotAfter: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	rule := &AuthorizationRule{IssuerDNRegex: `O=Let's Encrypt`, SANPattern: "*.example.com"}
+	matched, err := rule.Matches(facts)
+	if err != nil {
+		t.Fatalf("Matches failed: %v", err)
+	}
+	if !matched {
+		t.Fatal("expected rule to match")
+	}
+
+	rule = &AuthorizationRule{SANPattern: "*.other.com"}
+	matched, err = rule.Matches(facts)
+	if err != nil {
+		t.Fatalf("Matches failed: %v", err)
+	}
+	if matched {
+		t.Fatal("expected rule not to match a different SAN pattern")
+	}
+
+	rule = &AuthorizationRule{MaxUses: 1, MatchedCount: 1}
+	matched, err = rule.Matches(facts)
+	if err != nil {
+		t.Fatalf("Matches failed: %v", err)
+	}
+	if matched {
+		t.Fatal("expected exhausted rule not to match")
+	}
+}
+
+func TestAuthorizationRuleStorage(t *testing.T) {
+	stateDir := t.TempDir()
+
+	id, err := newRuleID()
+	if err != nil {
+		t.Fatalf("newRuleID failed: %v", err)
+	}
+	rule := &AuthorizationRule{ID: id, SANPattern: "*.example.com", MaxUses: 5}
+	if err := saveAuthorizationRule(stateDir, rule); err != nil {
+		t.Fatalf("saveAuthorizationRule failed: %v", err)
+	}
+
+	rules, err := loadAuthorizationRules(stateDir)
+	if err != nil {
+		t.Fatalf("loadAuthorizationRules failed: %v", err)
+	}
+	if len(rules) != 1 || rules[0].ID != id || rules[0].SANPattern != "*.example.com" {
+		t.Fatalf("unexpected rules after save: %+v", rules)
+	}
+
+	if err := removeAuthorizationRule(stateDir, id); err != nil {
+		t.Fatalf("removeAuthorizationRule failed: %v", err)
+	}
+	rules, err = loadAuthorizationRules(stateDir)
+	if err != nil {
+		t.Fatalf("loadAuthorizationRules failed: %v", err)
+	}
+	if len(rules) != 0 {
+		t.Fatalf("expected no rules after removal, got %+v", rules)
+	}
+}
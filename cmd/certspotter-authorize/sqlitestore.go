@@ -0,0 +1,113 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla
+// Public License, v. 2.0. If a copy of the MPL was not distributed
+// with this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This software is distributed WITHOUT A WARRANTY OF ANY KIND.
+// See the Mozilla Public License for details.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteNotifiedStore implements NotifiedStore on top of a SQLite database,
+// keyed by TBS hash, so that a fleet of hosts can share one authorization
+// database instead of syncing hidden dotfiles around.
+type SQLiteNotifiedStore struct {
+	db *sql.DB
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS notifications (
+	tbs_sha256    TEXT PRIMARY KEY,
+	authorized_at INTEGER NOT NULL,
+	authorized_by TEXT NOT NULL DEFAULT '',
+	reason        TEXT NOT NULL DEFAULT '',
+	expires_at    INTEGER NOT NULL DEFAULT 0
+)`
+
+// NewSQLiteNotifiedStore opens (creating if necessary) the SQLite database
+// at path and ensures its schema exists.
+//
+// SQLite only allows one writer at a time, so the connection is given a
+// busy_timeout (to make a concurrent writer block and retry instead of
+// immediately failing with SQLITE_BUSY) and the connection pool is capped
+// at one connection (so database/sql serializes writers on the Go side
+// instead of opening a second connection that would just contend for the
+// same lock). This matters for -batch -jobs>1 against a shared database.
+func NewSQLiteNotifiedStore(path string) (*SQLiteNotifiedStore, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("error opening sqlite state database %q: %w", path, err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error initializing sqlite state database %q: %w", path, err)
+	}
+	return &SQLiteNotifiedStore{db: db}, nil
+}
+
+func (s *SQLiteNotifiedStore) IsNotified(ctx context.Context, tbsHash [32]byte) (bool, error) {
+	tbsHex := hex.EncodeToString(tbsHash[:])
+	var expiresAt int64
+	err := s.db.QueryRowContext(ctx, `SELECT expires_at FROM notifications WHERE tbs_sha256 = ?`, tbsHex).Scan(&expiresAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("error querying sqlite state database: %w", err)
+	}
+	if expiresAt != 0 && time.Now().Unix() >= expiresAt {
+		return false, nil
+	}
+	return true, nil
+}
+
+func (s *SQLiteNotifiedStore) MarkNotified(ctx context.Context, tbsHash [32]byte, opts MarkOptions) error {
+	tbsHex := hex.EncodeToString(tbsHash[:])
+	var expiresAt int64
+	if !opts.ExpiresAt.IsZero() {
+		expiresAt = opts.ExpiresAt.Unix()
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO notifications (tbs_sha256, authorized_at, authorized_by, reason, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(tbs_sha256) DO UPDATE SET
+			authorized_at = excluded.authorized_at,
+			authorized_by = excluded.authorized_by,
+			reason = excluded.reason,
+			expires_at = excluded.expires_at
+	`, tbsHex, time.Now().Unix(), opts.AuthorizedBy, opts.Reason, expiresAt)
+	if err != nil {
+		return fmt.Errorf("error writing to sqlite state database: %w", err)
+	}
+	return nil
+}
+
+// GC removes rows whose expiry has passed, so the database doesn't grow
+// unboundedly with expired authorizations.  Rows with expires_at = 0
+// (permanent authorizations) are never removed.
+func (s *SQLiteNotifiedStore) GC(ctx context.Context) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM notifications WHERE expires_at != 0 AND expires_at <= ?`, time.Now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("error removing expired rows from sqlite state database: %w", err)
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error counting removed rows: %w", err)
+	}
+	return int(removed), nil
+}
+
+func (s *SQLiteNotifiedStore) Close() error {
+	return s.db.Close()
+}
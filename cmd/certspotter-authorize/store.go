@@ -0,0 +1,220 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla
+// Public License, v. 2.0. If a copy of the MPL was not distributed
+// with this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This software is distributed WITHOUT A WARRANTY OF ANY KIND.
+// See the Mozilla Public License for details.
+
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MarkOptions controls how long an authorization lasts and who granted it
+// and why.  A zero-value MarkOptions authorizes the certificate permanently,
+// matching certspotter-authorize's historical behavior.
+type MarkOptions struct {
+	ExpiresAt    time.Time // zero means the authorization never expires
+	Reason       string
+	AuthorizedBy string
+}
+
+// NotifiedStore records which certificates - identified by the SHA-256
+// hash of their TBSCertificate - have already been authorized, so that
+// certspotter can suppress future notifications about them.  It plays the
+// same role for authorization state that monitor.StateProvider plays for
+// monitor state: a narrow interface that lets the on-disk layout be swapped
+// out for something else (e.g. a shared database) without touching callers.
+type NotifiedStore interface {
+	// IsNotified reports whether tbsHash is currently authorized.  A marker
+	// whose expiry has passed is treated the same as no marker at all.
+	IsNotified(ctx context.Context, tbsHash [32]byte) (bool, error)
+
+	// MarkNotified authorizes tbsHash according to opts.  It is idempotent:
+	// marking an already-authorized hash again just replaces its opts.
+	MarkNotified(ctx context.Context, tbsHash [32]byte, opts MarkOptions) error
+
+	// GC removes authorizations whose expiry has passed and returns how
+	// many were removed.
+	GC(ctx context.Context) (int, error)
+
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+// FilesystemNotifiedStore implements NotifiedStore using the same on-disk
+// layout that certspotter-authorize has always used, and that
+// monitor/fsstate.go reads from: <stateDir>/certs/<xx>/.<hash>.notified.
+type FilesystemNotifiedStore struct {
+	stateDir string
+}
+
+// NewFilesystemNotifiedStore returns a NotifiedStore backed by marker files
+// under stateDir.
+func NewFilesystemNotifiedStore(stateDir string) *FilesystemNotifiedStore {
+	return &FilesystemNotifiedStore{stateDir: stateDir}
+}
+
+func (s *FilesystemNotifiedStore) markerPath(tbsHash [32]byte) (string, error) {
+	tbsHex := hex.EncodeToString(tbsHash[:])
+	if len(tbsHex) < 2 {
+		return "", fmt.Errorf("TBS hash hex is too short: %d characters", len(tbsHex))
+	}
+	return filepath.Join(s.stateDir, "certs", tbsHex[0:2], "."+tbsHex+".notified"), nil
+}
+
+// markerPayload is the content of a marker file that carries an expiry,
+// reason, or authorizer.  A zero-length marker file means "authorized
+// forever," preserving the format certspotter-authorize has always written.
+type markerPayload struct {
+	AuthorizedAt time.Time `json:"authorized_at"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	AuthorizedBy string    `json:"authorized_by,omitempty"`
+}
+
+func (s *FilesystemNotifiedStore) IsNotified(ctx context.Context, tbsHash [32]byte) (bool, error) {
+	path, err := s.markerPath(tbsHash)
+	if err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("error reading marker file %s: %w", path, err)
+	}
+	if len(data) == 0 {
+		return true, nil
+	}
+	var payload markerPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return false, fmt.Errorf("error parsing marker file %s: %w", path, err)
+	}
+	if !payload.ExpiresAt.IsZero() && !payload.ExpiresAt.After(time.Now()) {
+		return false, nil
+	}
+	return true, nil
+}
+
+func (s *FilesystemNotifiedStore) MarkNotified(ctx context.Context, tbsHash [32]byte, opts MarkOptions) error {
+	path, err := s.markerPath(tbsHash)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if !opts.ExpiresAt.IsZero() || opts.Reason != "" || opts.AuthorizedBy != "" {
+		data, err = json.Marshal(markerPayload{
+			AuthorizedAt: time.Now(),
+			ExpiresAt:    opts.ExpiresAt,
+			Reason:       opts.Reason,
+			AuthorizedBy: opts.AuthorizedBy,
+		})
+		if err != nil {
+			return fmt.Errorf("error marshaling marker file: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0666); err != nil {
+		return fmt.Errorf("error creating marker file: %w", err)
+	}
+	return nil
+}
+
+func (s *FilesystemNotifiedStore) Close() error {
+	return nil
+}
+
+// GC walks the marker files under s.stateDir and removes any whose expiry
+// has passed, so that disk usage doesn't grow unboundedly with expired
+// authorizations.  It returns the number of markers removed.
+func (s *FilesystemNotifiedStore) GC(ctx context.Context) (int, error) {
+	certsDir := filepath.Join(s.stateDir, "certs")
+	shards, err := os.ReadDir(certsDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("error reading %s: %w", certsDir, err)
+	}
+
+	removed := 0
+	for _, shard := range shards {
+		if !shard.IsDir() {
+			continue
+		}
+		shardDir := filepath.Join(certsDir, shard.Name())
+		markers, err := os.ReadDir(shardDir)
+		if err != nil {
+			return removed, fmt.Errorf("error reading %s: %w", shardDir, err)
+		}
+		for _, marker := range markers {
+			if marker.IsDir() || !strings.HasSuffix(marker.Name(), ".notified") {
+				continue
+			}
+			path := filepath.Join(shardDir, marker.Name())
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return removed, fmt.Errorf("error reading %s: %w", path, err)
+			}
+			if len(data) == 0 {
+				continue // permanent marker
+			}
+			var payload markerPayload
+			if err := json.Unmarshal(data, &payload); err != nil {
+				continue // leave unparseable markers alone
+			}
+			if payload.ExpiresAt.IsZero() || payload.ExpiresAt.After(time.Now()) {
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				return removed, fmt.Errorf("error removing %s: %w", path, err)
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// openNotifiedStore opens the NotifiedStore named by storeURL.  An empty
+// storeURL selects the traditional filesystem layout rooted at stateDir.
+// storeURL is normally populated from the -store flag or the
+// CERTSPOTTER_STATE_URL environment variable, e.g.
+// "sqlite:///var/lib/certspotter/state.db".
+func openNotifiedStore(stateDir string, storeURL string) (NotifiedStore, error) {
+	if storeURL == "" {
+		return NewFilesystemNotifiedStore(stateDir), nil
+	}
+
+	u, err := url.Parse(storeURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state store URL %q: %w", storeURL, err)
+	}
+
+	switch u.Scheme {
+	case "", "file":
+		dir := u.Path
+		if dir == "" {
+			dir = stateDir
+		}
+		return NewFilesystemNotifiedStore(dir), nil
+	case "sqlite":
+		return NewSQLiteNotifiedStore(u.Path)
+	default:
+		return nil, fmt.Errorf("state store URL %q has unsupported scheme %q", storeURL, u.Scheme)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright (C) 2026 Opsmate, Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla
+// Public License, v. 2.0. If a copy of the MPL was not distributed
+// with this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This software is distributed WITHOUT A WARRANTY OF ANY KIND.
+// See the Mozilla Public License for details.
+
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFilesystemNotifiedStore(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	store := NewFilesystemNotifiedStore(stateDir)
+
+	certDER, err := parseCertificate([]byte(testCertPEM))
+	if err != nil {
+		t.Fatalf("parseCertificate failed: %v", err)
+	}
+	tbsHash, err := computeTBSHash(certDER)
+	if err != nil {
+		t.Fatalf("computeTBSHash failed: %v", err)
+	}
+
+	if notified, err := store.IsNotified(ctx, tbsHash); err != nil {
+		t.Fatalf("IsNotified failed: %v", err)
+	} else if notified {
+		t.Fatal("IsNotified returned true before MarkNotified was called")
+	}
+
+	if err := store.MarkNotified(ctx, tbsHash, MarkOptions{}); err != nil {
+		t.Fatalf("MarkNotified failed: %v", err)
+	}
+
+	if notified, err := store.IsNotified(ctx, tbsHash); err != nil {
+		t.Fatalf("IsNotified failed: %v", err)
+	} else if !notified {
+		t.Fatal("IsNotified returned false after MarkNotified was called")
+	}
+
+	// Verify the marker lives at the path monitor/fsstate.go expects.
+	tbsHex := hex.EncodeToString(tbsHash[:])
+	expectedPath := filepath.Join(stateDir, "certs", tbsHex[0:2], "."+tbsHex+".notified")
+	if !fileExists(expectedPath) {
+		t.Fatalf("marker file does not exist: %s", expectedPath)
+	}
+
+	// MarkNotified is idempotent.
+	if err := store.MarkNotified(ctx, tbsHash, MarkOptions{}); err != nil {
+		t.Fatalf("second MarkNotified failed: %v", err)
+	}
+}
+
+func TestOpenNotifiedStoreDefaultsToFilesystem(t *testing.T) {
+	stateDir := t.TempDir()
+
+	store, err := openNotifiedStore(stateDir, "")
+	if err != nil {
+		t.Fatalf("openNotifiedStore failed: %v", err)
+	}
+	defer store.Close()
+
+	if _, ok := store.(*FilesystemNotifiedStore); !ok {
+		t.Fatalf("openNotifiedStore returned %T, expected *FilesystemNotifiedStore", store)
+	}
+}
+
+func TestOpenNotifiedStoreSQLite(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "state.db")
+
+	store, err := openNotifiedStore(t.TempDir(), "sqlite://"+dbPath)
+	if err != nil {
+		t.Fatalf("openNotifiedStore failed: %v", err)
+	}
+	defer store.Close()
+
+	if _, ok := store.(*SQLiteNotifiedStore); !ok {
+		t.Fatalf("openNotifiedStore returned %T, expected *SQLiteNotifiedStore", store)
+	}
+}
+
+func TestOpenNotifiedStoreUnsupportedScheme(t *testing.T) {
+	if _, err := openNotifiedStore(t.TempDir(), "mongodb://localhost/state"); err == nil {
+		t.Fatal("openNotifiedStore did not reject unsupported scheme")
+	}
+}
+
+func newTestSQLiteNotifiedStore(t *testing.T) *SQLiteNotifiedStore {
+	t.Helper()
+	store, err := NewSQLiteNotifiedStore(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteNotifiedStore failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteNotifiedStore(t *testing.T) {
+	ctx := context.Background()
+	store := newTestSQLiteNotifiedStore(t)
+
+	certDER, err := parseCertificate([]byte(testCertPEM))
+	if err != nil {
+		t.Fatalf("parseCertificate failed: %v", err)
+	}
+	tbsHash, err := computeTBSHash(certDER)
+	if err != nil {
+		t.Fatalf("computeTBSHash failed: %v", err)
+	}
+
+	if notified, err := store.IsNotified(ctx, tbsHash); err != nil {
+		t.Fatalf("IsNotified failed: %v", err)
+	} else if notified {
+		t.Fatal("IsNotified returned true before MarkNotified was called")
+	}
+
+	if err := store.MarkNotified(ctx, tbsHash, MarkOptions{Reason: "test", AuthorizedBy: "tester"}); err != nil {
+		t.Fatalf("MarkNotified failed: %v", err)
+	}
+
+	if notified, err := store.IsNotified(ctx, tbsHash); err != nil {
+		t.Fatalf("IsNotified failed: %v", err)
+	} else if !notified {
+		t.Fatal("IsNotified returned false after MarkNotified was called")
+	}
+
+	// MarkNotified is idempotent.
+	if err := store.MarkNotified(ctx, tbsHash, MarkOptions{Reason: "test", AuthorizedBy: "tester"}); err != nil {
+		t.Fatalf("second MarkNotified failed: %v", err)
+	}
+}
+
+func TestSQLiteNotifiedStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	store := newTestSQLiteNotifiedStore(t)
+
+	var tbsHash [32]byte
+	tbsHash[0] = 0x7
+
+	if err := store.MarkNotified(ctx, tbsHash, MarkOptions{ExpiresAt: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatalf("MarkNotified failed: %v", err)
+	}
+
+	if notified, err := store.IsNotified(ctx, tbsHash); err != nil {
+		t.Fatalf("IsNotified failed: %v", err)
+	} else if notified {
+		t.Fatal("IsNotified returned true for an expired row")
+	}
+}
+
+func TestSQLiteNotifiedStoreGC(t *testing.T) {
+	ctx := context.Background()
+	store := newTestSQLiteNotifiedStore(t)
+
+	var expired, permanent [32]byte
+	expired[0], permanent[0] = 1, 2
+
+	if err := store.MarkNotified(ctx, expired, MarkOptions{ExpiresAt: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatalf("MarkNotified failed: %v", err)
+	}
+	if err := store.MarkNotified(ctx, permanent, MarkOptions{}); err != nil {
+		t.Fatalf("MarkNotified failed: %v", err)
+	}
+
+	removed, err := store.GC(ctx)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("GC removed %d rows, expected 1", removed)
+	}
+
+	if notified, err := store.IsNotified(ctx, permanent); err != nil {
+		t.Fatalf("IsNotified failed: %v", err)
+	} else if !notified {
+		t.Fatal("GC removed the permanent row")
+	}
+}
+
+// TestSQLiteNotifiedStoreConcurrentMarkNotified mirrors what -batch -jobs>1
+// does against a shared SQLiteNotifiedStore: many goroutines calling
+// MarkNotified at once. Without a busy_timeout, SQLite fails concurrent
+// writers with SQLITE_BUSY instead of waiting for the lock.
+func TestSQLiteNotifiedStoreConcurrentMarkNotified(t *testing.T) {
+	ctx := context.Background()
+	store := newTestSQLiteNotifiedStore(t)
+
+	const n = 50
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var tbsHash [32]byte
+			tbsHash[0] = byte(i)
+			errs[i] = store.MarkNotified(ctx, tbsHash, MarkOptions{})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("concurrent MarkNotified %d failed: %v", i, err)
+		}
+	}
+}